@@ -0,0 +1,89 @@
+//go:build windows
+
+// Package daemon runs the reconcile loop that keeps a Windows node's managed services in their desired state
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows/svc"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/manager"
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/winsvc/svcdiag"
+)
+
+// managedServices are the Windows services WMCO is responsible for keeping in their desired state
+var managedServices = []string{"kubelet", "containerd", "windows_exporter"}
+
+// Daemon drives the reconcile loop that keeps the Windows node's managed services in their desired state
+type Daemon struct {
+	mgr manager.Manager
+	log logr.Logger
+}
+
+// NewDaemon returns a Daemon that reconciles managedServices through mgr, logging through log
+func NewDaemon(mgr manager.Manager, log logr.Logger) *Daemon {
+	return &Daemon{mgr: mgr, log: log}
+}
+
+// reconcile ensures every managed service is in the given state. A service that fails to reach it has its
+// dependency graph dumped and logged at Error level alongside the failure, so an operator can diagnose the
+// failure without needing to RDP into the instance.
+func (d *Daemon) reconcile(state svc.State) {
+	for _, name := range managedServices {
+		service, err := d.mgr.OpenService(name)
+		if err != nil {
+			d.log.Error(err, "failed to open service", "service", name)
+			continue
+		}
+		err = d.mgr.EnsureServiceState(service, state)
+		service.Close()
+		if err == nil {
+			continue
+		}
+		d.log.Error(err, "failed to ensure service state", "service", name, "state", state)
+
+		dump, dumpErr := svcdiag.Dump(d.mgr, name)
+		if dumpErr != nil {
+			d.log.Error(dumpErr, "failed to dump service dependency graph", "service", name)
+			continue
+		}
+		d.log.Error(err, "service dependency graph", "service", name, "graph", string(dump))
+	}
+}
+
+// Run reconciles managedServices into the given desired state on every tick, and immediately re-reconciles
+// whenever SubscribeServiceChanges reports one of them transitioning out-of-band - e.g. an admin running
+// `sc stop`, or Windows Update restarting a service - instead of waiting for the next tick to notice. Run blocks
+// until ctx is cancelled.
+func (d *Daemon) Run(ctx context.Context, tick time.Duration, desired svc.State) error {
+	events, err := d.mgr.SubscribeServiceChanges(ctx, managedServices)
+	if err != nil {
+		return errors.Wrap(err, "failed to subscribe to managed service changes")
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	d.reconcile(desired)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.reconcile(desired)
+		case event, ok := <-events:
+			if !ok {
+				// The subscription is only torn down once ctx is cancelled, so ctx is already done and a following
+				// iteration of the select observes it and returns.
+				continue
+			}
+			d.log.Info("observed out-of-band service state change", "service", event.Name,
+				"oldState", event.OldState, "newState", event.NewState)
+			d.reconcile(desired)
+		}
+	}
+}
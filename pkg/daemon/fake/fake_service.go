@@ -0,0 +1,126 @@
+//go:build windows
+
+package fake
+
+import (
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/winsvc"
+)
+
+// FakeService is a mock implementation of winsvc.Service, backed by an in-memory fakeServiceList, allowing service
+// management logic to be exercised in unit tests without a real Windows service control manager
+type FakeService struct {
+	name            string
+	config          mgr.Config
+	status          svc.Status
+	serviceList     *fakeServiceList
+	recoveryActions []mgr.RecoveryAction
+	resetPeriod     uint32
+	recoveryCommand string
+	rebootMessage   string
+}
+
+func (s *FakeService) Close() error {
+	return nil
+}
+
+func (s *FakeService) Config() (mgr.Config, error) {
+	return s.config, nil
+}
+
+func (s *FakeService) Control(c svc.Cmd) (svc.Status, error) {
+	switch c {
+	case svc.Stop:
+		s.status.State = svc.Stopped
+	case svc.Pause:
+		s.status.State = svc.Paused
+	case svc.Continue:
+		s.status.State = svc.Running
+	}
+	s.serviceList.write(s.name, s)
+	return s.status, nil
+}
+
+func (s *FakeService) Delete() error {
+	return s.serviceList.remove(s.name)
+}
+
+// ListDependentServices returns the names of the services in serviceList that depend on this service and are in
+// the given activity state
+func (s *FakeService) ListDependentServices(state svc.ActivityState) ([]string, error) {
+	var dependents []string
+	for name, other := range s.serviceList.snapshot() {
+		if name == s.name {
+			continue
+		}
+		config, err := other.Config()
+		if err != nil {
+			return nil, err
+		}
+		dependsOnThis := false
+		for _, dependency := range config.Dependencies {
+			if dependency == s.name {
+				dependsOnThis = true
+				break
+			}
+		}
+		if !dependsOnThis || !matchesActivityState(other, state) {
+			continue
+		}
+		dependents = append(dependents, name)
+	}
+	return dependents, nil
+}
+
+func (s *FakeService) Query() (svc.Status, error) {
+	return s.status, nil
+}
+
+// RecoveryActions returns the recovery actions most recently set on the service, for tests to assert against
+func (s *FakeService) RecoveryActions() ([]mgr.RecoveryAction, error) {
+	return s.recoveryActions, nil
+}
+
+func (s *FakeService) ResetPeriod() (uint32, error) {
+	return s.resetPeriod, nil
+}
+
+func (s *FakeService) SetRecoveryActions(actions []mgr.RecoveryAction, resetPeriod uint32) error {
+	s.recoveryActions = actions
+	s.resetPeriod = resetPeriod
+	return nil
+}
+
+func (s *FakeService) SetRecoveryCommand(cmd string) error {
+	s.recoveryCommand = cmd
+	return nil
+}
+
+func (s *FakeService) SetRebootMessage(msg string) error {
+	s.rebootMessage = msg
+	return nil
+}
+
+func (s *FakeService) Start(args ...string) error {
+	s.status.State = svc.Running
+	s.serviceList.write(s.name, s)
+	return nil
+}
+
+// matchesActivityState reports whether the given service's current state falls within the requested activity state
+func matchesActivityState(service winsvc.Service, state svc.ActivityState) bool {
+	if state == svc.AnyActivity {
+		return true
+	}
+	status, err := service.Query()
+	if err != nil {
+		return false
+	}
+	isActive := status.State != svc.Stopped
+	if state == svc.Active {
+		return isActive
+	}
+	return !isActive
+}
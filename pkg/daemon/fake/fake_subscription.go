@@ -0,0 +1,69 @@
+//go:build windows
+
+package fake
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/manager"
+)
+
+// fakeSubscription mocks out the real manager's service change notification subscription: it watches a set of
+// service names and emits a manager.ServiceEvent on events whenever one of them transitions to a new state
+type fakeSubscription struct {
+	mu        sync.Mutex
+	names     map[string]bool
+	lastState map[string]svc.State
+	events    chan manager.ServiceEvent
+	closed    bool
+}
+
+func newFakeSubscription(names []string) *fakeSubscription {
+	sub := &fakeSubscription{
+		names:     make(map[string]bool, len(names)),
+		lastState: make(map[string]svc.State, len(names)),
+		events:    make(chan manager.ServiceEvent),
+	}
+	for _, name := range names {
+		sub.names[name] = true
+	}
+	return sub
+}
+
+// notify emits a ServiceEvent if name is watched by this subscription and state differs from the last state
+// observed for it. The event is dropped if the subscriber isn't currently receiving, mirroring the real
+// subscription's behavior of not blocking the notifier on a slow consumer, and if the subscription has already
+// been closed.
+func (s *fakeSubscription) notify(name string, state svc.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || !s.names[name] {
+		return
+	}
+	old := s.lastState[name]
+	if old == state {
+		return
+	}
+	s.lastState[name] = state
+	event := manager.ServiceEvent{Name: name, OldState: old, NewState: state, Timestamp: time.Now()}
+	select {
+	case s.events <- event:
+	default:
+	}
+}
+
+// close closes the subscription's event channel, sharing notify's mutex so that a notification already in flight
+// finishes before the channel is closed out from under it - closing it directly would race with a concurrent
+// notify's send and panic.
+func (s *fakeSubscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.events)
+}
@@ -3,6 +3,7 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -10,20 +11,31 @@ import (
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/mgr"
 
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/manager"
 	"github.com/openshift/windows-machine-config-operator/pkg/daemon/winsvc"
 )
 
 // fakeServiceList mocks out the state of all services on a Windows instance
 type fakeServiceList struct {
-	m    *sync.Mutex
-	svcs map[string]winsvc.Service
+	m           *sync.Mutex
+	svcs        map[string]winsvc.Service
+	subscribers []*fakeSubscription
 }
 
-// write overwrites the given service to the svcs map
+// write overwrites the given service to the svcs map, and notifies any subscriber watching it of its new state
 func (l *fakeServiceList) write(name string, svc winsvc.Service) {
 	l.m.Lock()
-	defer l.m.Unlock()
 	l.svcs[name] = svc
+	subs := append([]*fakeSubscription(nil), l.subscribers...)
+	l.m.Unlock()
+
+	status, err := svc.Query()
+	if err != nil {
+		return
+	}
+	for _, sub := range subs {
+		sub.notify(name, status.State)
+	}
 }
 
 // read returns the entry with the given name, and a bool indicating if it exists or not
@@ -45,6 +57,17 @@ func (l *fakeServiceList) listServiceNames() []string {
 	return names
 }
 
+// snapshot returns a shallow copy of the svcs map, safe for a caller to range over without holding the lock
+func (l *fakeServiceList) snapshot() map[string]winsvc.Service {
+	l.m.Lock()
+	defer l.m.Unlock()
+	svcs := make(map[string]winsvc.Service, len(l.svcs))
+	for name, svc := range l.svcs {
+		svcs[name] = svc
+	}
+	return svcs
+}
+
 // remove deletes the entry with the given name, throwing an error if it doesn't exist
 func (l *fakeServiceList) remove(name string) error {
 	l.m.Lock()
@@ -57,6 +80,36 @@ func (l *fakeServiceList) remove(name string) error {
 	return nil
 }
 
+// subscribe registers a new fakeSubscription watching the given service names, seeded with each one's current
+// state so that the first observed transition has an accurate OldState
+func (l *fakeServiceList) subscribe(names []string) *fakeSubscription {
+	l.m.Lock()
+	defer l.m.Unlock()
+	sub := newFakeSubscription(names)
+	for _, name := range names {
+		if existing, ok := l.svcs[name]; ok {
+			if status, err := existing.Query(); err == nil {
+				sub.lastState[name] = status.State
+			}
+		}
+	}
+	l.subscribers = append(l.subscribers, sub)
+	return sub
+}
+
+// unsubscribe removes the given subscription and closes its event channel
+func (l *fakeServiceList) unsubscribe(sub *fakeSubscription) {
+	l.m.Lock()
+	for i, s := range l.subscribers {
+		if s == sub {
+			l.subscribers = append(l.subscribers[:i], l.subscribers[i+1:]...)
+			break
+		}
+	}
+	l.m.Unlock()
+	sub.close()
+}
+
 func newFakeServiceList() *fakeServiceList {
 	return &fakeServiceList{
 		m:    &sync.Mutex{},
@@ -75,7 +128,8 @@ type testMgr struct {
 // the service is started; these arguments are distinct from
 // the arguments passed to Service.Start or via the "Start
 // parameters" field in the service's Properties dialog box.
-func (t *testMgr) CreateService(name, exepath string, config mgr.Config, args ...string) (winsvc.Service, error) {
+func (t *testMgr) CreateService(name, exepath string, config mgr.Config, recovery *manager.RecoveryActions,
+	args ...string) (winsvc.Service, error) {
 	// Throw an error if the service already exists
 	if _, ok := t.svcList.read(name); ok {
 		return nil, errors.New("service already exists")
@@ -89,6 +143,12 @@ func (t *testMgr) CreateService(name, exepath string, config mgr.Config, args ..
 		},
 		serviceList: t.svcList,
 	}
+	if recovery != nil {
+		service.recoveryActions = recovery.Actions
+		service.resetPeriod = recovery.ResetPeriod
+		service.rebootMessage = recovery.RebootMessage
+		service.recoveryCommand = recovery.Command
+	}
 	t.svcList.write(name, &service)
 	return &service, nil
 }
@@ -135,11 +195,7 @@ func (t *testMgr) EnsureServiceState(service winsvc.Service, state svc.State) er
 	case svc.Running:
 		return service.Start()
 	case svc.Stopped:
-		fakeService, ok := service.(*FakeService)
-		if !ok {
-			return fmt.Errorf("service is not correct type")
-		}
-		dependentServices, err := t.listDependentServices(fakeService.name)
+		dependentServices, err := service.ListDependentServices(svc.Active)
 		if err != nil {
 			return err
 		}
@@ -161,23 +217,45 @@ func (t *testMgr) EnsureServiceState(service winsvc.Service, state svc.State) er
 	}
 }
 
-func (t *testMgr) listDependentServices(serviceName string) ([]string, error) {
-	var dependencies []string
-	for name, svc := range t.svcList.svcs {
-		if name == serviceName {
-			continue
-		}
-		config, err := svc.Config()
-		if err != nil {
-			return nil, err
-		}
-		for _, s := range config.Dependencies {
-			if s == serviceName {
-				dependencies = append(dependencies, name)
-			}
+func (t *testMgr) EnsureRecoveryActions(name string, actions ...mgr.RecoveryAction) error {
+	service, err := t.OpenService(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open service %q", name)
+	}
+	current, err := service.RecoveryActions()
+	if err != nil {
+		return errors.Wrapf(err, "failed to query recovery actions for service %q", name)
+	}
+	if recoveryActionsEqual(current, actions) {
+		return nil
+	}
+	resetPeriod, err := service.ResetPeriod()
+	if err != nil {
+		return errors.Wrapf(err, "failed to query reset period for service %q", name)
+	}
+	return service.SetRecoveryActions(actions, resetPeriod)
+}
+
+func (t *testMgr) SubscribeServiceChanges(ctx context.Context, names []string) (<-chan manager.ServiceEvent, error) {
+	sub := t.svcList.subscribe(names)
+	go func() {
+		<-ctx.Done()
+		t.svcList.unsubscribe(sub)
+	}()
+	return sub.events, nil
+}
+
+// recoveryActionsEqual reports whether a and b contain the same recovery actions in the same order
+func recoveryActionsEqual(a, b []mgr.RecoveryAction) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
-	return dependencies, nil
+	return true
 }
 
 func NewTestMgr(existingServices map[string]*FakeService) *testMgr {
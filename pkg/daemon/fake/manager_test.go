@@ -0,0 +1,77 @@
+//go:build windows
+
+package fake
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func TestEnsureRecoveryActions(t *testing.T) {
+	actions := []mgr.RecoveryAction{{Type: mgr.ServiceRestart}}
+
+	t.Run("sets recovery actions on a service with none", func(t *testing.T) {
+		testMgr := NewTestMgr(nil)
+		if _, err := testMgr.CreateService("kubelet", "kubelet.exe", mgr.Config{}, nil); err != nil {
+			t.Fatalf("unexpected error creating service: %s", err)
+		}
+		if err := testMgr.EnsureRecoveryActions("kubelet", actions...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		service, _ := testMgr.svcList.read("kubelet")
+		got, err := service.RecoveryActions()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != 1 || got[0] != actions[0] {
+			t.Fatalf("expected recovery actions %v, got %v", actions, got)
+		}
+	})
+
+	t.Run("is a no-op when the current recovery actions already match", func(t *testing.T) {
+		testMgr := NewTestMgr(nil)
+		if _, err := testMgr.CreateService("kubelet", "kubelet.exe", mgr.Config{}, nil); err != nil {
+			t.Fatalf("unexpected error creating service: %s", err)
+		}
+		if err := testMgr.EnsureRecoveryActions("kubelet", actions...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		service, _ := testMgr.svcList.read("kubelet")
+		fakeService := service.(*FakeService)
+		// Swap in an equal but distinct slice: a no-op call leaves it in place, while re-applying the actions would
+		// replace it with the slice passed to EnsureRecoveryActions.
+		sentinel := []mgr.RecoveryAction{{Type: mgr.ServiceRestart}}
+		fakeService.recoveryActions = sentinel
+
+		if err := testMgr.EnsureRecoveryActions("kubelet", actions...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if &fakeService.recoveryActions[0] != &sentinel[0] {
+			t.Fatalf("expected EnsureRecoveryActions to skip re-applying unchanged actions, got %v",
+				fakeService.recoveryActions)
+		}
+	})
+
+	t.Run("re-applies when the current recovery actions differ", func(t *testing.T) {
+		testMgr := NewTestMgr(nil)
+		if _, err := testMgr.CreateService("kubelet", "kubelet.exe", mgr.Config{}, nil); err != nil {
+			t.Fatalf("unexpected error creating service: %s", err)
+		}
+		if err := testMgr.EnsureRecoveryActions("kubelet", actions...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		newActions := []mgr.RecoveryAction{{Type: mgr.ServiceRestart}, {Type: mgr.RunCommand}}
+		if err := testMgr.EnsureRecoveryActions("kubelet", newActions...); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		service, _ := testMgr.svcList.read("kubelet")
+		got, err := service.RecoveryActions()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(got) != len(newActions) {
+			t.Fatalf("expected recovery actions %v, got %v", newActions, got)
+		}
+	})
+}
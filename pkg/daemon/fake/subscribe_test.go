@@ -0,0 +1,67 @@
+//go:build windows
+
+package fake
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// TestSubscribeServiceChanges exercises Start/Control racing against Unsubscribe (triggered by ctx cancellation)
+// to guard against a notify-after-close panic: a notification for a Control/Start call that's already in flight
+// when the context is cancelled must either be delivered or dropped, never sent on a closed channel.
+func TestSubscribeServiceChanges(t *testing.T) {
+	testMgr := NewTestMgr(nil)
+	if _, err := testMgr.CreateService("kubelet", "kubelet.exe", mgr.Config{}, nil); err != nil {
+		t.Fatalf("unexpected error creating service: %s", err)
+	}
+	service, _ := testMgr.svcList.read("kubelet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := testMgr.SubscribeServiceChanges(ctx, []string{"kubelet"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("notify panicked: %v", r)
+			}
+		}()
+		for i := 0; i < 1000; i++ {
+			if i%2 == 0 {
+				_ = service.Start()
+			} else {
+				_, _ = service.Control(svc.Stop)
+			}
+		}
+	}()
+
+	// Cancel partway through the flood of state changes above, racing Unsubscribe against an in-flight notify
+	time.Sleep(time.Millisecond)
+	cancel()
+
+	// Drain events until the channel is closed, so a send that does land isn't left blocking the notifier
+	drained := make(chan struct{})
+	go func() {
+		for range events {
+		}
+		close(drained)
+	}()
+
+	wg.Wait()
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for events channel to close")
+	}
+}
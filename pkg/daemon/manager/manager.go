@@ -3,12 +3,10 @@
 package manager
 
 import (
+	"context"
 	"fmt"
-	"reflect"
-	"unsafe"
 
 	"github.com/pkg/errors"
-	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/mgr"
 
@@ -16,8 +14,9 @@ import (
 )
 
 type Manager interface {
-	// CreateService creates a Windows service with the given configuration parameters
-	CreateService(string, string, mgr.Config, ...string) (winsvc.Service, error)
+	// CreateService creates a Windows service with the given configuration parameters. recovery may be nil, in
+	// which case the service control manager's default failure actions (take no action) are left in place.
+	CreateService(string, string, mgr.Config, *RecoveryActions, ...string) (winsvc.Service, error)
 	// GetServices returns a map of all the Windows services that exist on an instance.
 	// The keys are service names and values are empty structs, used as 0 byte placeholders.
 	GetServices() (map[string]struct{}, error)
@@ -27,19 +26,45 @@ type Manager interface {
 	DeleteService(string) error
 	// EnsureServiceState ensures the service is in the given state
 	EnsureServiceState(winsvc.Service, svc.State) error
+	// EnsureRecoveryActions idempotently reconciles the named service's recovery actions to match the given list,
+	// leaving its reset period untouched. It is a no-op if the service's current recovery actions already match.
+	EnsureRecoveryActions(name string, actions ...mgr.RecoveryAction) error
+	// SubscribeServiceChanges streams a ServiceEvent for each state transition observed on the named services, until
+	// ctx is cancelled, after which the channel is closed. Transitions made by WMCO itself are reported as well, and
+	// an event is dropped if the receiver is not ready for it when it is observed.
+	SubscribeServiceChanges(ctx context.Context, names []string) (<-chan ServiceEvent, error)
 }
 
-// enumServiceStatus implements the ENUM_SERVICE_STATUS type as defined in the Windows API
-type enumServiceStatus struct {
-	ServiceName   *uint16
-	DisplayName   *uint16
-	ServiceStatus windows.SERVICE_STATUS
+// RecoveryActions describes how the service control manager should respond when a service fails
+type RecoveryActions struct {
+	// Actions are applied in order as the service fails repeatedly, the last action repeating for any failure
+	// once the list is exhausted
+	Actions []mgr.RecoveryAction
+	// ResetPeriod is the length of time, in seconds, with no failures after which the failure count is reset to 0
+	ResetPeriod uint32
+	// RebootMessage is broadcast before rebooting, used when an action's type is mgr.ComputerReboot
+	RebootMessage string
+	// Command is run when an action's type is mgr.RunCommand
+	Command string
 }
 
-// enumDependentServicesW is a handle to the EnumDependentServicesW syscall
-// https://learn.microsoft.com/en-us/windows/win32/api/winsvc/nf-winsvc-enumdependentservicesw
-// This is global to prevent having to load the dll into memory and search for the API call every time it is used
-var enumDependentServicesW = windows.NewLazySystemDLL("Advapi32.dll").NewProc("EnumDependentServicesW")
+// apply configures the given service with these recovery actions
+func (r *RecoveryActions) apply(service winsvc.Service) error {
+	if err := service.SetRecoveryActions(r.Actions, r.ResetPeriod); err != nil {
+		return errors.Wrap(err, "failed to set recovery actions")
+	}
+	if r.RebootMessage != "" {
+		if err := service.SetRebootMessage(r.RebootMessage); err != nil {
+			return errors.Wrap(err, "failed to set reboot message")
+		}
+	}
+	if r.Command != "" {
+		if err := service.SetRecoveryCommand(r.Command); err != nil {
+			return errors.Wrap(err, "failed to set recovery command")
+		}
+	}
+	return nil
+}
 
 // manager is defined as a way for us to redefine the function signatures of mgr.Mgr, so that they can fulfill
 // the Mgr interface. When used directly, functions like mgr.Mgr's CreateService() returns a *mgr.Service type. This
@@ -48,10 +73,20 @@ var enumDependentServicesW = windows.NewLazySystemDLL("Advapi32.dll").NewProc("E
 // return values can be cast to the Service interface.
 type manager mgr.Mgr
 
-func (m *manager) CreateService(name, exepath string, config mgr.Config, args ...string) (winsvc.Service, error) {
+func (m *manager) CreateService(name, exepath string, config mgr.Config, recovery *RecoveryActions,
+	args ...string) (winsvc.Service, error) {
 	underlyingMgr := (*mgr.Mgr)(m)
 	service, err := underlyingMgr.CreateService(name, exepath, config, args...)
-	return winsvc.Service(service), err
+	if err != nil {
+		return nil, err
+	}
+	winSvc := winsvc.Service(service)
+	if recovery != nil {
+		if err := recovery.apply(winSvc); err != nil {
+			return nil, errors.Wrapf(err, "failed to configure recovery actions for service %q", name)
+		}
+	}
+	return winSvc, nil
 }
 
 func (m *manager) GetServices() (map[string]struct{}, error) {
@@ -117,12 +152,7 @@ func (m *manager) EnsureServiceState(service winsvc.Service, state svc.State) er
 		}
 	case svc.Stopped:
 		// Before we can stop this service, we need to make sure all services dependent on this service are stopped
-		// The service must be cast to the actual type so we can get its handle
-		winSvc, ok := service.(*mgr.Service)
-		if !ok {
-			return fmt.Errorf("service is not correct type")
-		}
-		dependentServices, err := m.listDependentServices(winSvc.Handle)
+		dependentServices, err := service.ListDependentServices(svc.Active)
 		if err != nil {
 			return errors.Wrap(err, "error finding dependent services")
 		}
@@ -147,54 +177,41 @@ func (m *manager) EnsureServiceState(service winsvc.Service, state svc.State) er
 	return winsvc.WaitForState(service, state)
 }
 
-// listDependentServices returns a list of names of all services dependent on the given service
-func (m *manager) listDependentServices(serviceHandle windows.Handle) ([]string, error) {
-	// Borrowing the main steps done here from the golang windows/mgr library's ListServices() function, as the
-	// EnumServicesStatusEx syscall has a very similar way of being called.
-	// https://cs.opensource.google/go/x/sys/+/refs/tags/v0.1.0:windows/svc/mgr/mgr.go;l=176
-	var serviceBuffer []byte
-	var bytesNeeded, returnedServiceCount uint32
-
-	// The documentation for this syscall says it should be ran at least twice. First to determine the size of the
-	// buffer it will return, and then to actually capture the data with an allocated buffer. As the count of dependent
-	// services can change in between calls, it may need to be ran more than twice.
-	for {
-		var p *byte
-		if len(serviceBuffer) > 0 {
-			p = &serviceBuffer[0]
-		}
-		// Returned error from `Call` will always be non-nil
-		success, _, err := enumDependentServicesSyscall(serviceHandle, windows.SERVICE_STATE_ALL, p,
-			uint32(len(serviceBuffer)), &bytesNeeded, &returnedServiceCount)
-		if success != 0 {
-			// a non-zero return value indicates the syscall completed successfully, and serviceBuffer has been filled
-			// with the requested data.
-			break
-		}
-		if err != windows.ERROR_MORE_DATA {
-			return nil, errors.Wrapf(err, "received unexpected error from enumDependentServicesSyscall")
-		}
-		if bytesNeeded <= uint32(len(serviceBuffer)) {
-			return nil, err
-		}
-		serviceBuffer = make([]byte, bytesNeeded)
+func (m *manager) EnsureRecoveryActions(name string, actions ...mgr.RecoveryAction) error {
+	service, err := m.OpenService(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open service %q", name)
+	}
+	defer service.Close()
+
+	current, err := service.RecoveryActions()
+	if err != nil {
+		return errors.Wrapf(err, "failed to query recovery actions for service %q", name)
+	}
+	if recoveryActionsEqual(current, actions) {
+		return nil
 	}
-	// If no services are dependent on this service, return successfully
-	if returnedServiceCount == 0 {
-		return nil, nil
+	resetPeriod, err := service.ResetPeriod()
+	if err != nil {
+		return errors.Wrapf(err, "failed to query reset period for service %q", name)
+	}
+	if err := service.SetRecoveryActions(actions, resetPeriod); err != nil {
+		return errors.Wrapf(err, "failed to set recovery actions for service %q", name)
 	}
-	// create a slice based on the buffer that was returned to us, so that we can iterate through it
-	var services []enumServiceStatus
-	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&services))
-	hdr.Data = uintptr(unsafe.Pointer(&serviceBuffer[0]))
-	hdr.Len = int(returnedServiceCount)
-	hdr.Cap = int(returnedServiceCount)
+	return nil
+}
 
-	var dependencies []string
-	for _, s := range services {
-		dependencies = append(dependencies, windows.UTF16PtrToString(s.ServiceName))
+// recoveryActionsEqual reports whether a and b contain the same recovery actions in the same order
+func recoveryActionsEqual(a, b []mgr.RecoveryAction) bool {
+	if len(a) != len(b) {
+		return false
 	}
-	return dependencies, nil
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func New() (Manager, error) {
@@ -205,12 +222,3 @@ func New() (Manager, error) {
 
 	return (*manager)(newMgr), nil
 }
-
-// enumDependentServicesSyscall is a wrapper around enumDependentServicesW.Call with the correct argument casting
-// Refer to the API documentation for an explanation of the arguments:
-// https://learn.microsoft.com/en-us/windows/win32/api/winsvc/nf-winsvc-enumdependentservicesw
-func enumDependentServicesSyscall(hService windows.Handle, dwServiceState uint32, lpServices *byte, cbBufSize uint32,
-	pcbBytesNeeded *uint32, lpServicesReturned *uint32) (uintptr, uintptr, error) {
-	return enumDependentServicesW.Call(uintptr(hService), uintptr(dwServiceState), uintptr(unsafe.Pointer(lpServices)),
-		uintptr(cbBufSize), uintptr(unsafe.Pointer(pcbBytesNeeded)), uintptr(unsafe.Pointer(lpServicesReturned)))
-}
@@ -0,0 +1,192 @@
+//go:build windows
+
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/winsvc"
+)
+
+// ServiceEvent describes an observed transition in a service's state
+type ServiceEvent struct {
+	// Name is the service the transition was observed on
+	Name string
+	// OldState is the state the service was in before the transition
+	OldState svc.State
+	// NewState is the state the service transitioned into
+	NewState svc.State
+	// Timestamp is when the transition was observed
+	Timestamp time.Time
+}
+
+// eventSink is a ServiceEvent channel that can be closed concurrently with sends to it. A plain close(ch) races
+// with a notification callback that is still in flight - Microsoft documents that the callback registered with
+// SubscribeServiceChangeNotifications may fire even after UnsubscribeServiceChangeNotifications has returned - and
+// a send on a closed channel panics regardless of the select/default guard in send(). Guarding every send and
+// the close itself with the same RWMutex makes the close wait for any in-flight send to finish first.
+type eventSink struct {
+	mu     sync.RWMutex
+	ch     chan ServiceEvent
+	closed bool
+}
+
+func newEventSink() *eventSink {
+	return &eventSink{ch: make(chan ServiceEvent)}
+}
+
+// send delivers event, dropping it if nobody is currently receiving or if the sink has been closed
+func (s *eventSink) send(event ServiceEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+	default:
+	}
+}
+
+// close closes the underlying channel, waiting for any send already in progress to complete. Safe to call more
+// than once.
+func (s *eventSink) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+}
+
+// serviceWatch holds the resources and state backing a single service's change notification subscription
+type serviceWatch struct {
+	name      string
+	service   winsvc.Service
+	watchID   uintptr
+	token     uintptr
+	lastState svc.State
+	events    *eventSink
+}
+
+// watchRegistry maps the watch ID passed as the callback context of SubscribeServiceChangeNotifications back to
+// the serviceWatch it was registered for. A single callback trampoline is shared across every subscription, since
+// windows.NewCallback hands out trampolines from a small, process-wide pool.
+var watchRegistry = struct {
+	sync.Mutex
+	next    uintptr
+	watches map[uintptr]*serviceWatch
+}{watches: make(map[uintptr]*serviceWatch)}
+
+// serviceChangeCallback is invoked by the service control manager, on its own notification thread, whenever a
+// watched service's status changes. The callback passed to SubscribeServiceChangeNotifications receives the
+// notification type first and the registered callback context second, so the watch ID is the second argument.
+var serviceChangeCallback = windows.NewCallback(func(notification, watchID uintptr) uintptr {
+	watchRegistry.Lock()
+	w, ok := watchRegistry.watches[watchID]
+	watchRegistry.Unlock()
+	if ok {
+		w.notify()
+	}
+	return 0
+})
+
+// notify re-queries the watched service and, if its state has changed since the last observation, emits a
+// ServiceEvent. The event is dropped if the subscriber isn't currently receiving, so that a slow consumer can't
+// block the service control manager's notification thread.
+func (w *serviceWatch) notify() {
+	status, err := w.service.Query()
+	if err != nil {
+		return
+	}
+	if status.State == w.lastState {
+		return
+	}
+	event := ServiceEvent{Name: w.name, OldState: w.lastState, NewState: status.State, Timestamp: time.Now()}
+	w.lastState = status.State
+	w.events.send(event)
+}
+
+// SubscribeServiceChanges registers for out-of-band state change notifications on the named services, streaming
+// each observed transition as a ServiceEvent until ctx is cancelled. The returned channel is closed once every
+// subscription has been released. Intended to let the reconcile loop react to a service being stopped or restarted
+// by something other than WMCO - e.g. an admin running `sc stop`, or Windows Update restarting a service - instead
+// of waiting for the next reconcile tick.
+func (m *manager) SubscribeServiceChanges(ctx context.Context, names []string) (<-chan ServiceEvent, error) {
+	sink := newEventSink()
+	watches := make([]*serviceWatch, 0, len(names))
+
+	for _, name := range names {
+		w, err := m.watchService(name, sink)
+		if err != nil {
+			releaseWatches(watches)
+			return nil, errors.Wrapf(err, "failed to subscribe to service %q", name)
+		}
+		watches = append(watches, w)
+	}
+
+	go func() {
+		<-ctx.Done()
+		releaseWatches(watches)
+		sink.close()
+	}()
+
+	return sink.ch, nil
+}
+
+// watchService opens the named service and registers it for SERVICE_NOTIFY_STATUS_CHANGE notifications, returning
+// the serviceWatch that owns the service handle for the lifetime of the subscription
+func (m *manager) watchService(name string, events *eventSink) (*serviceWatch, error) {
+	service, err := m.OpenService(name)
+	if err != nil {
+		return nil, err
+	}
+	winSvc, ok := service.(*mgr.Service)
+	if !ok {
+		service.Close()
+		return nil, fmt.Errorf("service %q is not correct type", name)
+	}
+	status, err := service.Query()
+	if err != nil {
+		service.Close()
+		return nil, err
+	}
+
+	w := &serviceWatch{name: name, service: service, lastState: status.State, events: events}
+
+	watchRegistry.Lock()
+	watchRegistry.next++
+	w.watchID = watchRegistry.next
+	watchRegistry.watches[w.watchID] = w
+	watchRegistry.Unlock()
+
+	if err := windows.SubscribeServiceChangeNotifications(winSvc.Handle, windows.SC_EVENT_STATUS_CHANGE,
+		serviceChangeCallback, w.watchID, &w.token); err != nil {
+		watchRegistry.Lock()
+		delete(watchRegistry.watches, w.watchID)
+		watchRegistry.Unlock()
+		service.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// releaseWatches unsubscribes and closes the service handle owned by each given watch
+func releaseWatches(watches []*serviceWatch) {
+	for _, w := range watches {
+		windows.UnsubscribeServiceChangeNotifications(w.token)
+		watchRegistry.Lock()
+		delete(watchRegistry.watches, w.watchID)
+		watchRegistry.Unlock()
+		w.service.Close()
+	}
+}
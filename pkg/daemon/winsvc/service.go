@@ -0,0 +1,40 @@
+//go:build windows
+
+package winsvc
+
+import (
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Service is implemented by any type representing a single Windows service, allowing callers to query and control
+// a service without depending on the concrete type backing it (*mgr.Service on a real instance,
+// fake.FakeService in tests)
+type Service interface {
+	// Close releases the handle to the service
+	Close() error
+	// Config retrieves the configuration parameters of the service
+	Config() (mgr.Config, error)
+	// Control sends a control request to the service and returns its status immediately after sending the request
+	Control(c svc.Cmd) (svc.Status, error)
+	// Delete marks the service for deletion from the service control manager database
+	Delete() error
+	// ListDependentServices returns the names of the services that are dependent on this service and are in the
+	// given activity state
+	ListDependentServices(state svc.ActivityState) ([]string, error)
+	// Query returns the current status of the service
+	Query() (svc.Status, error)
+	// RecoveryActions returns the actions the service control manager will take in response to this service failing
+	RecoveryActions() ([]mgr.RecoveryAction, error)
+	// ResetPeriod returns the length of time, in seconds, with no failures after which the failure count is reset
+	ResetPeriod() (uint32, error)
+	// SetRecoveryActions configures the actions the service control manager should take in response to this
+	// service failing, resetting the failure count after resetPeriod seconds with no failures
+	SetRecoveryActions(actions []mgr.RecoveryAction, resetPeriod uint32) error
+	// SetRecoveryCommand sets the command run when a recovery action's type is mgr.RunCommand
+	SetRecoveryCommand(cmd string) error
+	// SetRebootMessage sets the message broadcast before a recovery action reboots the computer
+	SetRebootMessage(msg string) error
+	// Start starts the service, passing the given arguments to it
+	Start(args ...string) error
+}
@@ -0,0 +1,118 @@
+//go:build windows
+
+// Package svcdiag provides diagnostics for troubleshooting a failed Windows service reconcile, by walking the
+// dependency graph of the services involved and describing the state of each one found.
+package svcdiag
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows/svc"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/manager"
+)
+
+// maxDepth bounds how many edges are followed away from a root service, so that an unexpectedly deep or
+// misconfigured dependency graph cannot cause unbounded recursion
+const maxDepth = 8
+
+// maxNodes bounds the total number of services described across a single Dump call, so that an unexpectedly large
+// dependency graph cannot cause unbounded memory use
+const maxNodes = 256
+
+// node is the JSON representation of a single service and the portion of the dependency graph reachable from it
+type node struct {
+	Name           string  `json:"Name"`
+	DisplayName    string  `json:"DisplayName"`
+	State          uint32  `json:"State"`
+	StartType      uint32  `json:"StartType"`
+	BinaryPathName string  `json:"BinaryPathName"`
+	Dependencies   []*node `json:"Dependencies"`
+	Dependents     []*node `json:"Dependents"`
+}
+
+// walker walks a service dependency graph, keeping track of the services that have already been described so that
+// a cycle in the graph, or the graph exceeding maxNodes, ends the walk early instead of looping or growing unbounded
+type walker struct {
+	mgr     manager.Manager
+	visited map[string]bool
+	count   int
+}
+
+// Dump walks the dependency graph of each root service, and returns a JSON document describing the state,
+// configuration, and upstream and downstream dependency graph of each one reached. It is intended to be logged
+// alongside an EnsureServiceState error so that an operator can diagnose a failed reconcile without needing to
+// inspect the instance directly.
+func Dump(mgr manager.Manager, roots ...string) ([]byte, error) {
+	w := &walker{mgr: mgr, visited: make(map[string]bool)}
+	nodes := make([]*node, 0, len(roots))
+	for _, root := range roots {
+		n, err := w.walk(root, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error walking service graph for %q", root)
+		}
+		if n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	return json.Marshal(nodes)
+}
+
+// walk describes the named service, recursing into its upstream dependencies and downstream dependents. It returns
+// a nil node without error if the service was already visited, if depth is past maxDepth, or if the node count has
+// reached maxNodes, so that hitting either limit simply truncates that branch of the graph rather than failing the
+// dump.
+func (w *walker) walk(name string, depth int) (*node, error) {
+	if w.visited[name] || depth > maxDepth || w.count >= maxNodes {
+		return nil, nil
+	}
+	w.visited[name] = true
+	w.count++
+
+	service, err := w.mgr.OpenService(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening service %q", name)
+	}
+	defer service.Close()
+
+	status, err := service.Query()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error querying service %q", name)
+	}
+	config, err := service.Config()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting config of service %q", name)
+	}
+	dependents, err := service.ListDependentServices(svc.AnyActivity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing dependents of service %q", name)
+	}
+
+	n := &node{
+		Name:           name,
+		DisplayName:    config.DisplayName,
+		State:          uint32(status.State),
+		StartType:      uint32(config.StartType),
+		BinaryPathName: config.BinaryPathName,
+	}
+	for _, dependency := range config.Dependencies {
+		child, err := w.walk(dependency, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			n.Dependencies = append(n.Dependencies, child)
+		}
+	}
+	for _, dependent := range dependents {
+		child, err := w.walk(dependent, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if child != nil {
+			n.Dependents = append(n.Dependents, child)
+		}
+	}
+	return n, nil
+}
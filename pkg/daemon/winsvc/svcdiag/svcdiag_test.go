@@ -0,0 +1,108 @@
+//go:build windows
+
+package svcdiag
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/openshift/windows-machine-config-operator/pkg/daemon/fake"
+)
+
+func TestDump(t *testing.T) {
+	testMgr := fake.NewTestMgr(nil)
+	if _, err := testMgr.CreateService("kubelet", "kubelet.exe", mgr.Config{DisplayName: "Kubelet"}, nil); err != nil {
+		t.Fatalf("unexpected error creating service: %s", err)
+	}
+	if _, err := testMgr.CreateService("kube-proxy", "kube-proxy.exe",
+		mgr.Config{DisplayName: "Kube Proxy", Dependencies: []string{"kubelet"}}, nil); err != nil {
+		t.Fatalf("unexpected error creating service: %s", err)
+	}
+
+	out, err := Dump(testMgr, "kubelet")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var nodes []node
+	if err := json.Unmarshal(out, &nodes); err != nil {
+		t.Fatalf("failed to unmarshal dump output: %s", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "kubelet" || nodes[0].DisplayName != "Kubelet" {
+		t.Fatalf("expected a single root node for kubelet, got %+v", nodes)
+	}
+	if len(nodes[0].Dependents) != 1 || nodes[0].Dependents[0].Name != "kube-proxy" {
+		t.Fatalf("expected kube-proxy to be listed as a dependent of kubelet, got %+v", nodes[0].Dependents)
+	}
+}
+
+// TestDumpBreaksCycles ensures a cycle in the dependency graph (here, a and b each depending on the other) is
+// detected via the visited set and doesn't recurse forever
+func TestDumpBreaksCycles(t *testing.T) {
+	testMgr := fake.NewTestMgr(nil)
+	if _, err := testMgr.CreateService("a", "a.exe", mgr.Config{Dependencies: []string{"b"}}, nil); err != nil {
+		t.Fatalf("unexpected error creating service: %s", err)
+	}
+	if _, err := testMgr.CreateService("b", "b.exe", mgr.Config{Dependencies: []string{"a"}}, nil); err != nil {
+		t.Fatalf("unexpected error creating service: %s", err)
+	}
+
+	out, err := Dump(testMgr, "a")
+	if err != nil {
+		t.Fatalf("unexpected error, Dump should break cycles rather than fail: %s", err)
+	}
+
+	var nodes []node
+	if err := json.Unmarshal(out, &nodes); err != nil {
+		t.Fatalf("failed to unmarshal dump output: %s", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "a" {
+		t.Fatalf("expected a single root node for a, got %+v", nodes)
+	}
+	// b is reachable from a, but a is not reachable again from b - the cycle must be broken, not just truncated
+	if len(nodes[0].Dependencies) != 1 || nodes[0].Dependencies[0].Name != "b" {
+		t.Fatalf("expected a to depend on b, got %+v", nodes[0].Dependencies)
+	}
+	if len(nodes[0].Dependencies[0].Dependencies) != 0 {
+		t.Fatalf("expected the cycle back to a to be broken, got %+v", nodes[0].Dependencies[0].Dependencies)
+	}
+}
+
+// TestDumpDepthCap ensures a dependency chain longer than maxDepth is truncated rather than fully walked
+func TestDumpDepthCap(t *testing.T) {
+	testMgr := fake.NewTestMgr(nil)
+	chainLength := maxDepth + 5
+	for i := 0; i < chainLength; i++ {
+		name := fmt.Sprintf("svc%d", i)
+		config := mgr.Config{}
+		if i > 0 {
+			config.Dependencies = []string{fmt.Sprintf("svc%d", i-1)}
+		}
+		if _, err := testMgr.CreateService(name, name+".exe", config, nil); err != nil {
+			t.Fatalf("unexpected error creating service: %s", err)
+		}
+	}
+
+	out, err := Dump(testMgr, fmt.Sprintf("svc%d", chainLength-1))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	depth := 0
+	var nodes []node
+	if err := json.Unmarshal(out, &nodes); err != nil {
+		t.Fatalf("failed to unmarshal dump output: %s", err)
+	}
+	for n := &nodes[0]; len(n.Dependencies) > 0; n = n.Dependencies[0] {
+		depth++
+	}
+	if depth > maxDepth {
+		t.Fatalf("expected dependency chain to be truncated at depth %d, walked to depth %d", maxDepth, depth)
+	}
+	if depth == 0 {
+		t.Fatal("expected at least one dependency to be walked")
+	}
+}
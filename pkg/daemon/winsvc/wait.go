@@ -0,0 +1,36 @@
+//go:build windows
+
+package winsvc
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// serviceStateTimeout is the maximum amount of time to wait for a service to reach a desired state
+const serviceStateTimeout = 30 * time.Second
+
+// serviceStatePollInterval is the amount of time to wait in between checks of a service's state
+const serviceStatePollInterval = 100 * time.Millisecond
+
+// WaitForState polls the given service until it reports the given state, returning an error if serviceStateTimeout
+// elapses first
+func WaitForState(service Service, state svc.State) error {
+	timeout := time.After(serviceStateTimeout)
+	for {
+		status, err := service.Query()
+		if err != nil {
+			return err
+		}
+		if status.State == state {
+			return nil
+		}
+		select {
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for service to reach state %d", state)
+		case <-time.After(serviceStatePollInterval):
+		}
+	}
+}